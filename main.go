@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
 
@@ -19,6 +20,10 @@ import (
 // GroupName คือ DNS provider group ที่ใช้สำหรับ webhook
 var GroupName = os.Getenv("GROUP_NAME")
 
+// Version คือเวอร์ชันของ webhook นี้ ใช้ประกอบ User-Agent ที่ส่งไปยัง Technitium API
+// กำหนดได้ตอน build ผ่าน -ldflags "-X main.Version=..." ถ้าไม่กำหนดจะเป็น "dev"
+var Version = "dev"
+
 func main() {
 	if GroupName == "" {
 		panic("GROUP_NAME must be specified")
@@ -33,6 +38,10 @@ func main() {
 type technitiumDNSProviderSolver struct {
 	client           *kubernetes.Clientset
 	connectorCreator func(serverURL, token string) *technitiumConnector
+
+	// ctx ถูก cancel เมื่อ cert-manager สั่ง shutdown ผ่าน stop channel ที่ส่งมาใน
+	// Initialize ใช้เพื่อยกเลิก HTTP request ที่ค้างอยู่แทนที่จะรอจน client timeout
+	ctx context.Context
 }
 
 // technitiumDNSProviderConfig โครงสร้างข้อมูลการตั้งค่าที่จำเป็นสำหรับการเชื่อมต่อกับ Technitium DNS API
@@ -48,6 +57,26 @@ type technitiumDNSProviderConfig struct {
 
 	// TTL คือค่า TTL ของ TXT record ที่สร้างขึ้น (default: 60)
 	TTL int `json:"ttl,omitempty"`
+
+	// RetryPolicy ปรับแต่ง retry behaviour ตอนเรียก Technitium API (ถ้าไม่ระบุจะใช้ค่า default)
+	RetryPolicy *retryPolicyConfig `json:"retryPolicy,omitempty"`
+
+	// PropagationCheck ถ้าเปิดใช้งาน จะรอให้ nameserver ที่ระบุเห็น TXT record ที่เพิ่งสร้าง
+	// ก่อนที่ Present จะ return (ถ้าไม่ระบุจะไม่เช็ค)
+	PropagationCheck *propagationCheckConfig `json:"propagationCheck,omitempty"`
+}
+
+// retryPolicyConfig คือการตั้งค่า retry แบบ optional ต่อ Issuer ฟิลด์ที่ไม่ได้ระบุ (เป็น 0)
+// จะใช้ค่า default ของ connector แทน
+type retryPolicyConfig struct {
+	// MaxAttempts คือจำนวนครั้งสูงสุดที่จะลองเรียก API ซ้ำ (default: 5)
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+
+	// BaseDelaySeconds คือ delay เริ่มต้นก่อนเพิ่มแบบ exponential ในหน่วยวินาที (default: 0.5)
+	BaseDelaySeconds float64 `json:"baseDelaySeconds,omitempty"`
+
+	// MaxDelaySeconds คือ delay สูงสุดที่ backoff จะไม่เกินในหน่วยวินาที (default: 8)
+	MaxDelaySeconds float64 `json:"maxDelaySeconds,omitempty"`
 }
 
 // Name คือชื่อของ DNS solver
@@ -59,17 +88,26 @@ func (c *technitiumDNSProviderSolver) Name() string {
 func (c *technitiumDNSProviderSolver) Present(ch *v1alpha1.ChallengeRequest) error {
 	klog.Infof("Presenting challenge for domain %s", ch.ResolvedFQDN)
 
-	connector, zone, ttl, err := c.createConnectorFromChallenge(ch)
+	ctx := c.requestContext()
+
+	connector, cfg, zone, ttl, err := c.createConnectorFromChallenge(ctx, ch)
 	if err != nil {
 		return err
 	}
 
 	// สร้าง TXT record สำหรับ challenge
-	err = connector.createTXTRecord(zone, ch.ResolvedFQDN, ch.Key, ttl)
+	err = connector.createTXTRecord(ctx, zone, ch.ResolvedFQDN, ch.Key, ttl)
 	if err != nil {
 		return fmt.Errorf("error creating TXT record: %v", err)
 	}
 
+	if cfg.PropagationCheck != nil {
+		klog.Infof("Waiting for TXT record to propagate to %v for %s", cfg.PropagationCheck.Nameservers, ch.ResolvedFQDN)
+		if err := waitForPropagation(ctx, *cfg.PropagationCheck, ch.ResolvedFQDN, ch.Key); err != nil {
+			return fmt.Errorf("error waiting for TXT record propagation: %v", err)
+		}
+	}
+
 	klog.Infof("Successfully presented challenge for domain %s", ch.ResolvedFQDN)
 	return nil
 }
@@ -78,13 +116,15 @@ func (c *technitiumDNSProviderSolver) Present(ch *v1alpha1.ChallengeRequest) err
 func (c *technitiumDNSProviderSolver) CleanUp(ch *v1alpha1.ChallengeRequest) error {
 	klog.Infof("Cleaning up challenge for domain %s", ch.ResolvedFQDN)
 
-	connector, zone, _, err := c.createConnectorFromChallenge(ch)
+	ctx := c.requestContext()
+
+	connector, _, zone, _, err := c.createConnectorFromChallenge(ctx, ch)
 	if err != nil {
 		return err
 	}
 
 	// ลบ TXT record
-	err = connector.deleteTXTRecord(zone, ch.ResolvedFQDN, ch.Key)
+	err = connector.deleteTXTRecord(ctx, zone, ch.ResolvedFQDN, ch.Key)
 	if err != nil {
 		return fmt.Errorf("error deleting TXT record: %v", err)
 	}
@@ -101,22 +141,38 @@ func (c *technitiumDNSProviderSolver) Initialize(kubeClientConfig *rest.Config,
 		return err
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
 	c.client = cl
 	c.connectorCreator = newTechnitiumConnector
+	c.ctx = ctx
 	return nil
 }
 
+// requestContext คืน context ที่ผูกกับ stop channel ของ webhook ถ้ามี (ตั้งค่าใน
+// Initialize) ไม่เช่นนั้น fallback เป็น context.Background()
+func (c *technitiumDNSProviderSolver) requestContext() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
 // createConnectorFromChallenge สร้าง connector และเตรียมข้อมูลที่จำเป็นจากคำขอ challenge
-func (c *technitiumDNSProviderSolver) createConnectorFromChallenge(ch *v1alpha1.ChallengeRequest) (*technitiumConnector, string, int, error) {
+func (c *technitiumDNSProviderSolver) createConnectorFromChallenge(ctx context.Context, ch *v1alpha1.ChallengeRequest) (*technitiumConnector, technitiumDNSProviderConfig, string, int, error) {
 	cfg, err := loadConfig(ch.Config)
 	if err != nil {
-		return nil, "", 0, fmt.Errorf("error loading config: %v", err)
+		return nil, cfg, "", 0, fmt.Errorf("error loading config: %v", err)
 	}
 
 	// ดึงค่า token จาก Secret
 	authToken, err := c.getTokenFromSecret(cfg, ch.ResourceNamespace)
 	if err != nil {
-		return nil, "", 0, fmt.Errorf("error getting auth token: %v", err)
+		return nil, cfg, "", 0, fmt.Errorf("error getting auth token: %v", err)
 	}
 
 	// กำหนดค่า TTL เริ่มต้นถ้าไม่ได้ระบุ
@@ -127,18 +183,26 @@ func (c *technitiumDNSProviderSolver) createConnectorFromChallenge(ch *v1alpha1.
 
 	connector := c.connectorCreator(cfg.ServerURL, authToken)
 
+	if cfg.RetryPolicy != nil {
+		connector.SetRetryPolicy(
+			cfg.RetryPolicy.MaxAttempts,
+			time.Duration(cfg.RetryPolicy.BaseDelaySeconds*float64(time.Second)),
+			time.Duration(cfg.RetryPolicy.MaxDelaySeconds*float64(time.Second)),
+		)
+	}
+
 	// หา zone ที่เหมาะสมถ้าไม่ได้ระบุ
 	zone := ch.ResolvedZone
 	if zone == "" {
 		klog.Infof("Zone not specified, attempting to find authoritative zone for %s", ch.ResolvedFQDN)
-		zone, err = connector.findAuthoritativeZone(ch.ResolvedFQDN)
+		zone, err = connector.findAuthoritativeZone(ctx, ch.ResolvedFQDN)
 		if err != nil {
-			return nil, "", 0, fmt.Errorf("error finding zone for domain %s: %v", ch.ResolvedFQDN, err)
+			return nil, cfg, "", 0, fmt.Errorf("error finding zone for domain %s: %v", ch.ResolvedFQDN, err)
 		}
 		klog.Infof("Found authoritative zone: %s", zone)
 	}
 
-	return connector, zone, ttl, nil
+	return connector, cfg, zone, ttl, nil
 }
 
 // ฟังก์ชั่นสำหรับอ่าน token จาก Kubernetes Secret
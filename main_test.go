@@ -1,6 +1,11 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 
 	acmetest "github.com/cert-manager/cert-manager/test/acme"
@@ -34,3 +39,125 @@ func TestRunsSuite(t *testing.T) {
 	fixture.RunExtended(t)
 
 }
+
+// TestCreateAndCleanupCoexistingTXTRecords จำลอง Technitium API เพื่อตรวจสอบว่า
+// เมื่อ Certificate มีทั้ง example.com และ *.example.com เป็น SAN (คนละ challenge
+// key แต่ resolve ไปที่ _acme-challenge.example.com เดียวกัน) ทั้งสอง value จะถูกเพิ่ม
+// และ query เจอพร้อมกันได้ ก่อนที่จะถูกลบทีละอันโดยไม่กระทบอีกอัน ซึ่งเป็น flow เดียวกับที่
+// RunConformance/RunExtended จะเจอเมื่อถูกเปิดใช้งาน
+func TestCreateAndCleanupCoexistingTXTRecords(t *testing.T) {
+	type record struct {
+		domain string
+		text   string
+	}
+	var records []record
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/user/session/get", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"ok"}`)
+	})
+	mux.HandleFunc("/api/zones/records/add", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("parse form: %v", err)
+		}
+		records = append(records, record{domain: r.Form.Get("domain"), text: r.Form.Get("text")})
+		fmt.Fprint(w, `{"status":"ok"}`)
+	})
+	mux.HandleFunc("/api/zones/records/get", func(w http.ResponseWriter, r *http.Request) {
+		domain := r.URL.Query().Get("domain")
+
+		var response struct {
+			Status   string `json:"status"`
+			Response struct {
+				Records []struct {
+					Name  string `json:"name"`
+					Type  string `json:"type"`
+					RData struct {
+						Text string `json:"text"`
+					} `json:"rData"`
+				} `json:"records"`
+			} `json:"response"`
+		}
+		response.Status = "ok"
+		for _, rec := range records {
+			if rec.domain != domain {
+				continue
+			}
+			entry := struct {
+				Name  string `json:"name"`
+				Type  string `json:"type"`
+				RData struct {
+					Text string `json:"text"`
+				} `json:"rData"`
+			}{Name: domain, Type: "TXT"}
+			entry.RData.Text = rec.text
+			response.Response.Records = append(response.Response.Records, entry)
+		}
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Fatalf("encode response: %v", err)
+		}
+	})
+	mux.HandleFunc("/api/zones/records/delete", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("parse form: %v", err)
+		}
+		domain := r.Form.Get("domain")
+		text := r.Form.Get("text")
+		for i, rec := range records {
+			if rec.domain == domain && rec.text == text {
+				records = append(records[:i], records[i+1:]...)
+				break
+			}
+		}
+		fmt.Fprint(w, `{"status":"ok"}`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	connector := newTechnitiumConnector(server.URL, "test-token")
+
+	ctx := context.Background()
+
+	const (
+		fqdn       = "_acme-challenge.example.com."
+		dnsZone    = "example.com"
+		keyForApex = "apex-challenge-key"
+		keyForWild = "wildcard-challenge-key"
+	)
+
+	if err := connector.createTXTRecord(ctx, dnsZone, fqdn, keyForApex, 60); err != nil {
+		t.Fatalf("create TXT record for apex challenge: %v", err)
+	}
+	if err := connector.createTXTRecord(ctx, dnsZone, fqdn, keyForWild, 60); err != nil {
+		t.Fatalf("create TXT record for wildcard challenge: %v", err)
+	}
+
+	existing, err := connector.getTXTRecords(ctx, dnsZone, fqdn)
+	if err != nil {
+		t.Fatalf("get TXT records: %v", err)
+	}
+	if !containsString(existing, keyForApex) || !containsString(existing, keyForWild) {
+		t.Fatalf("expected both challenge keys to be queryable, got %v", existing)
+	}
+
+	if err := connector.deleteTXTRecord(ctx, dnsZone, fqdn, keyForApex); err != nil {
+		t.Fatalf("delete TXT record for apex challenge: %v", err)
+	}
+
+	remaining, err := connector.getTXTRecords(ctx, dnsZone, fqdn)
+	if err != nil {
+		t.Fatalf("get TXT records after delete: %v", err)
+	}
+	if containsString(remaining, keyForApex) {
+		t.Fatalf("expected apex challenge key to be removed, got %v", remaining)
+	}
+	if !containsString(remaining, keyForWild) {
+		t.Fatalf("expected wildcard challenge key to remain, got %v", remaining)
+	}
+
+	if err := connector.deleteTXTRecord(ctx, dnsZone, fqdn, keyForWild); err != nil {
+		t.Fatalf("delete TXT record for wildcard challenge: %v", err)
+	}
+}
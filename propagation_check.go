@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+	"k8s.io/klog/v2"
+)
+
+// defaultPropagationTimeout คือเวลาสูงสุดที่จะรอให้ nameserver เห็น TXT record ถ้าไม่ได้ระบุ
+const defaultPropagationTimeout = 60 * time.Second
+
+// defaultPropagationInterval คือ interval ระหว่างรอบ poll ถ้าไม่ได้ระบุ
+const defaultPropagationInterval = 5 * time.Second
+
+// dnsQueryTimeout คือ timeout ของแต่ละ query ที่ยิงไปยัง nameserver แต่ละตัว
+const dnsQueryTimeout = 5 * time.Second
+
+// propagationCheckConfig ควบคุมการรอให้ TXT record ที่เพิ่งสร้างกระจายไปถึง nameserver
+// ที่ระบุก่อนที่ Present จะ return ใช้สำหรับ Technitium deployment ที่มี secondary NS
+// โอน zone ช้ากว่า primary ทำให้ cert-manager self-check เจอ SERVFAIL/ไม่มี TXT
+type propagationCheckConfig struct {
+	// Enabled เปิด/ปิดการเช็ค propagation (default: false)
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Nameservers คือ nameserver (host หรือ host:port, default port 53) ที่จะ query ตรงๆ
+	Nameservers []string `json:"nameservers,omitempty"`
+
+	// TimeoutSeconds คือเวลาสูงสุดที่จะรอให้ทุก nameserver เห็น record ก่อนจะ fail (default: 60)
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+
+	// IntervalSeconds คือ interval ระหว่างรอบที่จะ poll ซ้ำ (default: 5)
+	IntervalSeconds int `json:"intervalSeconds,omitempty"`
+}
+
+// waitForPropagation poll nameserver ที่ configured ไว้ทุกตัวจนกว่าทุกตัวจะเห็น TXT
+// record ที่มีค่าตรงกับ expected ที่ fqdn หรือจนกว่าจะหมดเวลา ถ้าไม่ได้เปิดใช้งานหรือไม่ได้
+// ระบุ nameserver ไว้จะถือว่าผ่านทันที
+func waitForPropagation(ctx context.Context, cfg propagationCheckConfig, fqdn, expected string) error {
+	if !cfg.Enabled || len(cfg.Nameservers) == 0 {
+		return nil
+	}
+
+	timeout := defaultPropagationTimeout
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	interval := defaultPropagationInterval
+	if cfg.IntervalSeconds > 0 {
+		interval = time.Duration(cfg.IntervalSeconds) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	pending := make(map[string]bool, len(cfg.Nameservers))
+	for _, ns := range cfg.Nameservers {
+		pending[ns] = true
+	}
+
+	for {
+		for ns := range pending {
+			ok, err := nameserverHasTXT(ns, fqdn, expected)
+			if err != nil {
+				klog.V(4).Infof("Propagation check against %s failed, will retry: %v", ns, err)
+				continue
+			}
+			if ok {
+				klog.V(4).Infof("Propagation confirmed on %s for %s", ns, fqdn)
+				delete(pending, ns)
+			}
+		}
+
+		if len(pending) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			remaining := make([]string, 0, len(pending))
+			for ns := range pending {
+				remaining = append(remaining, ns)
+			}
+			return fmt.Errorf("TXT record for %s did not propagate to nameservers %v within %s", fqdn, remaining, timeout)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// nameserverHasTXT query nameserver ที่ระบุตรงๆ ด้วย github.com/miekg/dns เพื่อดูว่ามี TXT
+// record ที่มีค่าตรงกับ expected อยู่ที่ fqdn แล้วหรือยัง
+func nameserverHasTXT(nameserver, fqdn, expected string) (bool, error) {
+	addr := nameserver
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "53")
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(fqdn), dns.TypeTXT)
+	msg.RecursionDesired = false
+
+	client := &dns.Client{Timeout: dnsQueryTimeout}
+	resp, _, err := client.Exchange(msg, addr)
+	if err != nil {
+		return false, fmt.Errorf("querying %s: %w", nameserver, err)
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return false, fmt.Errorf("nameserver %s returned rcode %s", nameserver, dns.RcodeToString[resp.Rcode])
+	}
+
+	for _, rr := range resp.Answer {
+		txt, ok := rr.(*dns.TXT)
+		if !ok {
+			continue
+		}
+		for _, value := range txt.Txt {
+			if value == expected {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
@@ -1,18 +1,54 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"golang.org/x/net/publicsuffix"
 	extapi "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/klog/v2"
 )
 
+// zoneLookupTimeout คือ timeout สำหรับ SOA/NS lookup ที่ใช้หา authoritative zone
+const zoneLookupTimeout = 5 * time.Second
+
+// zoneCacheTTL คือระยะเวลาที่ zone ที่หาเจอแล้วจะถูก cache ไว้ ก่อนจะหาใหม่
+const zoneCacheTTL = 10 * time.Minute
+
+// userAgent คือค่า User-Agent ที่ส่งไปกับทุก request ไปยัง Technitium API
+var userAgent = "cert-manager-technitium-webhook/" + Version
+
+// zoneCacheEntry เก็บผลลัพธ์ของ fqdn -> zone พร้อมเวลาหมดอายุ
+type zoneCacheEntry struct {
+	zone      string
+	expiresAt time.Time
+}
+
+// retryPolicy ควบคุมพฤติกรรม retry ของ technitiumConnector.do ค่า default มาจาก
+// defaultRetryPolicy แต่ override ได้ต่อ Issuer ผ่าน technitiumDNSProviderConfig.RetryPolicy
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+// defaultRetryPolicy คือ retry policy ที่ใช้เมื่อ Issuer ไม่ได้ระบุมาเอง (500ms -> 8s, สูงสุด 5 ครั้ง)
+var defaultRetryPolicy = retryPolicy{
+	maxAttempts: 5,
+	baseDelay:   500 * time.Millisecond,
+	maxDelay:    8 * time.Second,
+}
+
 // HTTPClient เป็น HTTP client ที่ใช้สำหรับการเชื่อมต่อกับ API
 var HTTPClient = &http.Client{
 	Timeout: 30 * time.Second,
@@ -28,21 +64,329 @@ var HTTPClient = &http.Client{
 type technitiumConnector struct {
 	serverURL string
 	authToken string
+
+	// resolver ใช้สำหรับ SOA/NS lookup ตอนหา authoritative zone โดยตรงจาก DNS
+	// แทนการถาม Technitium ทีละ label ค่า default คือ net.DefaultResolver
+	resolver *net.Resolver
+
+	zoneCacheMu sync.Mutex
+	zoneCache   map[string]zoneCacheEntry
+
+	// authModeOnce negotiate ว่า server รองรับการส่ง token ผ่าน X-Api-Token header
+	// หรือไม่ (Technitium DNS 11+) ทำครั้งเดียวแล้ว cache ผลไว้ที่ tokenInHeader
+	authModeOnce  sync.Once
+	tokenInHeader bool
+
+	retryPolicy retryPolicy
 }
 
 // newTechnitiumConnector สร้าง connector ใหม่สำหรับติดต่อกับ Technitium DNS API
 func newTechnitiumConnector(serverURL, token string) *technitiumConnector {
 	return &technitiumConnector{
-		serverURL: serverURL,
-		authToken: token,
+		serverURL:   serverURL,
+		authToken:   token,
+		resolver:    net.DefaultResolver,
+		zoneCache:   make(map[string]zoneCacheEntry),
+		retryPolicy: defaultRetryPolicy,
 	}
 }
 
-// ฟังก์ชั่นค้นหา zone ที่เหมาะสมกับ domain
-func (c *technitiumConnector) findAuthoritativeZone(fqdn string) (string, error) {
-	// เอา trailing dot ออกถ้ามี
-	domain := strings.TrimSuffix(fqdn, ".")
+// SetRetryPolicy ปรับ retry policy ของ connector ค่าที่ <= 0 จะถูกแทนที่ด้วยค่า default
+// เพื่อให้ตั้งค่าแค่บางฟิลด์จาก Issuer ได้โดยไม่ต้องระบุครบทุกตัว
+func (c *technitiumConnector) SetRetryPolicy(maxAttempts int, baseDelay, maxDelay time.Duration) {
+	policy := defaultRetryPolicy
+	if maxAttempts > 0 {
+		policy.maxAttempts = maxAttempts
+	}
+	if baseDelay > 0 {
+		policy.baseDelay = baseDelay
+	}
+	if maxDelay > 0 {
+		policy.maxDelay = maxDelay
+	}
+	c.retryPolicy = policy
+}
+
+// SetDNSServer กำหนดให้ resolver ที่ใช้หา authoritative zone ชี้ไปที่ DNS server
+// ที่ระบุแทน resolver ของระบบ สำหรับ test ที่ต้องการคุมว่าจะ query ไปที่ไหน
+// (คล้าย acmetest.SetDNSServer) solver ไม่ได้เรียก method นี้ connector ที่ solver
+// สร้างจึงใช้ net.DefaultResolver เสมอ
+func (c *technitiumConnector) SetDNSServer(server string) {
+	c.resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: zoneLookupTimeout}
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// do ส่ง request ไปยัง Technitium API ผ่าน HTTPClient โดยแนบ token ผ่าน X-Api-Token
+// header (หรือ fallback เป็น token ใน form/query สำหรับ server รุ่นเก่าที่ negotiate
+// ไว้ครั้งแรก) พร้อม User-Agent และ ctx ที่รับมาจากผู้เรียก เพื่อให้ยกเลิก request
+// ค้างได้เมื่อ cert-manager สั่ง shutdown ผ่าน stop channel
+//
+// ถ้าเจอ TCP reset/5xx/429 จะ retry แบบ exponential backoff พร้อม jitter ตาม
+// c.retryPolicy (honor Retry-After header ถ้า server ส่งมาให้) ก่อนยอมแพ้
+func (c *technitiumConnector) do(ctx context.Context, method, path string, form url.Values) ([]byte, error) {
+	c.ensureAuthMode(ctx)
+
+	if form == nil {
+		form = url.Values{}
+	}
+	if !c.tokenInHeader {
+		form.Set("token", c.authToken)
+	}
+
+	policy := c.retryPolicy
+	if policy.maxAttempts <= 0 {
+		policy = defaultRetryPolicy
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= policy.maxAttempts; attempt++ {
+		started := time.Now()
+		body, status, retryAfter, err := c.doOnce(ctx, method, path, form)
+
+		if err == nil && !isRetryableStatus(status) {
+			return body, nil
+		}
+
+		if err == nil {
+			err = fmt.Errorf("unexpected HTTP status %d", status)
+		}
+		lastErr = err
+
+		if attempt == policy.maxAttempts {
+			break
+		}
+
+		delay := retryAfter
+		if delay <= 0 {
+			delay = backoffDelay(policy, attempt)
+		}
+
+		klog.Warningf("Technitium API request %s %s failed (attempt %d/%d, status %d, elapsed %s): %v, retrying in %s",
+			method, path, attempt, policy.maxAttempts, status, time.Since(started), err, delay)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+
+	return nil, fmt.Errorf("giving up after %d attempts: %w", policy.maxAttempts, lastErr)
+}
+
+// doOnce ส่ง request ไปยัง Technitium API หนึ่งครั้ง คืนค่า body, HTTP status และ
+// ค่า Retry-After (ถ้ามี) เพื่อให้ do ตัดสินใจว่าควร retry หรือไม่
+func (c *technitiumConnector) doOnce(ctx context.Context, method, path string, form url.Values) ([]byte, int, time.Duration, error) {
+	endpoint := c.serverURL + path
+	var body io.Reader
+	if method == http.MethodGet {
+		if len(form) > 0 {
+			endpoint += "?" + form.Encode()
+		}
+	} else {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	if method != http.MethodGet {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if c.tokenInHeader {
+		req.Header.Set("X-Api-Token", c.authToken)
+	}
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, 0, err
+	}
+
+	return respBody, resp.StatusCode, parseRetryAfter(resp.Header.Get("Retry-After")), nil
+}
+
+// isRetryableStatus คืนค่า true สำหรับ HTTP status ที่ควร retry คือ 429, 503 และ 5xx อื่นๆ
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// parseRetryAfter แปลงค่า Retry-After header (วินาทีแบบตัวเลข หรือ HTTP-date) เป็น
+// duration คืนค่า 0 ถ้าไม่มีหรือ parse ไม่ได้ ซึ่งจะทำให้ do ใช้ backoff ของตัวเองแทน
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// backoffDelay คำนวณ delay แบบ exponential backoff พร้อม jitter สำหรับการ retry ครั้งที่ attempt
+func backoffDelay(policy retryPolicy, attempt int) time.Duration {
+	delay := policy.baseDelay << uint(attempt-1)
+	if delay <= 0 || delay > policy.maxDelay {
+		delay = policy.maxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// ensureAuthMode negotiate ว่า server รองรับ X-Api-Token header หรือไม่ โดยถาม
+// /api/user/session/get ครั้งเดียวแล้ว cache ผลไว้ ถ้า negotiate ไม่สำเร็จจะถือว่า
+// server เป็นรุ่นเก่าและ fallback ไปส่ง token ผ่าน form/query แทน
+func (c *technitiumConnector) ensureAuthMode(ctx context.Context) {
+	c.authModeOnce.Do(func() {
+		c.tokenInHeader = c.probeTokenHeaderSupport(ctx)
+		klog.V(4).Infof("Technitium auth negotiation: tokenInHeader=%v", c.tokenInHeader)
+	})
+}
+
+func (c *technitiumConnector) probeTokenHeaderSupport(ctx context.Context) bool {
+	endpoint := c.serverURL + "/api/user/session/get"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		klog.V(4).Infof("Could not build auth negotiation request, falling back to token in request body: %v", err)
+		return false
+	}
+	req.Header.Set("X-Api-Token", c.authToken)
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		klog.V(4).Infof("Auth negotiation request failed, falling back to token in request body: %v", err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		klog.V(4).Infof("Could not read auth negotiation response, falling back to token in request body: %v", err)
+		return false
+	}
+
+	var response struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		klog.V(4).Infof("Could not parse auth negotiation response, falling back to token in request body: %v", err)
+		return false
+	}
+
+	return response.Status == "ok"
+}
+
+// findAuthoritativeZone หา zone ที่เหมาะสมกับ domain โดยลองหา apex จริงจาก public
+// suffix list + NS lookup ก่อน แล้วค่อย fallback ไปถาม Technitium ทีละ label
+// ผลลัพธ์จะถูก cache ไว้ตาม fqdn บน connector ตัวนี้เป็นเวลา zoneCacheTTL จึงใช้ซ้ำได้เฉพาะ
+// การเรียกผ่าน connector ตัวเดียวกัน solver สร้าง connector ใหม่ทุกครั้งที่เรียก Present/CleanUp
+// ดังนั้น cache นี้ไม่ถูกใช้ร่วมกันระหว่างการเรียก Present/CleanUp แต่ละครั้ง
+func (c *technitiumConnector) findAuthoritativeZone(ctx context.Context, fqdn string) (string, error) {
+	domain := strings.ToLower(strings.TrimSuffix(fqdn, "."))
+
+	if zone, ok := c.lookupZoneCache(domain); ok {
+		klog.V(4).Infof("Using cached zone %s for %s", zone, domain)
+		return zone, nil
+	}
+
+	zone, err := c.findAuthoritativeZoneBySOA(ctx, domain)
+	if err != nil {
+		klog.V(4).Infof("SOA/NS based zone lookup failed for %s, falling back to Technitium: %v", domain, err)
+		zone, err = c.findAuthoritativeZoneByTechnitium(ctx, domain)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	c.storeZoneCache(domain, zone)
+	return zone, nil
+}
+
+// findAuthoritativeZoneBySOA ใช้ public suffix list ข้าม label ที่อยู่ที่หรือต่ำกว่า
+// eTLD เพื่อหาจุดเริ่มต้น (eTLD+1) แล้วค่อยๆ ไล่ลึกลงไปทีละ label โดยถาม NS record
+// ตรงๆ จาก DNS (แทน SOA เพราะ net.Resolver มาตรฐานไม่มี LookupSOA) เพื่อหา zone cut
+// ที่ลึกที่สุดที่ยังถูก delegate อยู่จริง
+func (c *technitiumConnector) findAuthoritativeZoneBySOA(ctx context.Context, domain string) (string, error) {
+	eTLDPlusOne, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return "", fmt.Errorf("could not determine public suffix for %s: %w", domain, err)
+	}
+
+	if _, err := c.lookupNS(ctx, eTLDPlusOne); err != nil {
+		return "", fmt.Errorf("no NS records found at %s: %w", eTLDPlusOne, err)
+	}
+	apex := eTLDPlusOne
+
+	remainder := strings.TrimSuffix(strings.TrimSuffix(domain, eTLDPlusOne), ".")
+	var subLabels []string
+	if remainder != "" {
+		subLabels = strings.Split(remainder, ".")
+	}
+
+	// ไล่จาก label ที่ใกล้ apex ที่สุดลงไปหา leaf เพื่อหา zone ที่ถูก delegate ลึกสุด
+	for i := len(subLabels) - 1; i >= 0; i-- {
+		candidate := subLabels[i] + "." + apex
+		if _, err := c.lookupNS(ctx, candidate); err != nil {
+			break
+		}
+		apex = candidate
+	}
+
+	return apex, nil
+}
+
+// lookupNS ถาม NS record ของ name ตรงๆ จาก DNS โดยใช้ resolver ของ connector
+func (c *technitiumConnector) lookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+	ctx, cancel := context.WithTimeout(ctx, zoneLookupTimeout)
+	defer cancel()
+	return c.resolver.LookupNS(ctx, name)
+}
+
+// lookupZoneCache คืนค่า zone ที่ cache ไว้สำหรับ fqdn ถ้ายังไม่หมดอายุ
+func (c *technitiumConnector) lookupZoneCache(fqdn string) (string, bool) {
+	c.zoneCacheMu.Lock()
+	defer c.zoneCacheMu.Unlock()
+
+	entry, ok := c.zoneCache[fqdn]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return "", false
+	}
+	return entry.zone, true
+}
+
+// storeZoneCache เก็บผลลัพธ์ fqdn -> zone ไว้ใช้ซ้ำจนกว่าจะหมดอายุ
+func (c *technitiumConnector) storeZoneCache(fqdn, zone string) {
+	c.zoneCacheMu.Lock()
+	defer c.zoneCacheMu.Unlock()
+
+	c.zoneCache[fqdn] = zoneCacheEntry{
+		zone:      zone,
+		expiresAt: time.Now().Add(zoneCacheTTL),
+	}
+}
 
+// findAuthoritativeZoneByTechnitium หา zone โดยถาม Technitium ทีละ label จากซ้ายไปขวา
+// ใช้เป็น fallback เมื่อ SOA/NS lookup ตรงๆ ใช้ไม่ได้ (เช่น network แยก หรือ zone ส่วนตัว)
+func (c *technitiumConnector) findAuthoritativeZoneByTechnitium(ctx context.Context, domain string) (string, error) {
 	// แยก domain ออกเป็นส่วนๆ แล้วค่อยๆตัดจากซ้ายทีละส่วน
 	parts := strings.Split(domain, ".")
 
@@ -52,22 +396,16 @@ func (c *technitiumConnector) findAuthoritativeZone(fqdn string) (string, error)
 		klog.V(4).Infof("Checking if %s is an authoritative zone", checkZone)
 
 		// ตรวจสอบว่า zone นี้มีอยู่จริงใน Technitium DNS Server หรือไม่
-		endpoint := fmt.Sprintf("%s/api/zones/records/get?token=%s&domain=%s&listZone=false",
-			c.serverURL, url.QueryEscape(c.authToken), url.QueryEscape(checkZone))
+		form := url.Values{}
+		form.Set("domain", checkZone)
+		form.Set("listZone", "false")
 
-		resp, err := HTTPClient.Get(endpoint)
+		body, err := c.do(ctx, http.MethodGet, "/api/zones/records/get", form)
 		if err != nil {
 			klog.V(4).Infof("Error querying zone %s: %v", checkZone, err)
 			continue
 		}
 
-		defer resp.Body.Close()
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			klog.V(4).Infof("Error reading response for zone %s: %v", checkZone, err)
-			continue
-		}
-
 		// ตรวจสอบว่าการเรียก API สำเร็จหรือไม่
 		var response struct {
 			Status   string `json:"status"`
@@ -92,44 +430,40 @@ func (c *technitiumConnector) findAuthoritativeZone(fqdn string) (string, error)
 		}
 	}
 
-	return "", fmt.Errorf("no authoritative zone found for domain %s", fqdn)
+	return "", fmt.Errorf("no authoritative zone found for domain %s", domain)
 }
 
-// สร้าง TXT record
-func (c *technitiumConnector) createTXTRecord(zone, fqdn, value string, ttl int) error {
+// สร้าง TXT record หากมี value นี้อยู่แล้วที่ fqdn นี้ (เช่นจาก challenge อื่นที่ชื่อซ้ำกัน
+// อย่าง _acme-challenge ของ example.com และ *.example.com) ถือว่าสำเร็จโดยไม่ต้องเรียก API เพิ่ม
+func (c *technitiumConnector) createTXTRecord(ctx context.Context, zone, fqdn, value string, ttl int) error {
 	// เอา trailing dot ออกถ้ามี
 	domain := strings.TrimSuffix(fqdn, ".")
 	zone = strings.TrimSuffix(zone, ".")
 
-	klog.Infof("Creating TXT record for %s with value %s (zone: %s, ttl: %d)", domain, value, zone, ttl)
+	existing, err := c.getTXTRecords(ctx, zone, fqdn)
+	if err != nil {
+		klog.V(4).Infof("Could not list existing TXT records for %s, proceeding to add anyway: %v", domain, err)
+	} else if containsString(existing, value) {
+		klog.Infof("TXT record for %s with value %s already exists, nothing to do", domain, value)
+		return nil
+	}
 
-	// สร้าง URL สำหรับเรียก API
-	endpoint := fmt.Sprintf("%s/api/zones/records/add", c.serverURL)
+	klog.Infof("Creating TXT record for %s with value %s (zone: %s, ttl: %d)", domain, value, zone, ttl)
 
 	// สร้างพารามิเตอร์สำหรับ HTTP request
-	data := url.Values{}
-	data.Set("token", c.authToken)
-	data.Set("domain", domain)
-	data.Set("zone", zone)
-	data.Set("type", "TXT")
-	data.Set("ttl", fmt.Sprintf("%d", ttl))
-	data.Set("text", value)
-	data.Set("splitText", "false")
-
-	// ส่ง HTTP request
-	resp, err := HTTPClient.PostForm(endpoint, data)
+	form := url.Values{}
+	form.Set("domain", domain)
+	form.Set("zone", zone)
+	form.Set("type", "TXT")
+	form.Set("ttl", fmt.Sprintf("%d", ttl))
+	form.Set("text", value)
+	form.Set("splitText", "false")
+
+	body, err := c.do(ctx, http.MethodPost, "/api/zones/records/add", form)
 	if err != nil {
 		klog.Errorf("HTTP request failed: %v", err)
 		return err
 	}
-	defer resp.Body.Close()
-
-	// อ่านผลลัพธ์
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		klog.Errorf("Failed to read response body: %v", err)
-		return err
-	}
 
 	// ตรวจสอบว่าการเรียก API สำเร็จหรือไม่
 	var response struct {
@@ -143,6 +477,10 @@ func (c *technitiumConnector) createTXTRecord(zone, fqdn, value string, ttl int)
 	}
 
 	if response.Status != "ok" {
+		if strings.Contains(strings.ToLower(response.ErrorMessage), "already exist") {
+			klog.Infof("TXT record for %s already exists according to API, treating as success", domain)
+			return nil
+		}
 		klog.Errorf("API error response: %s", string(body))
 		return fmt.Errorf("API error: %s", response.ErrorMessage)
 	}
@@ -151,41 +489,36 @@ func (c *technitiumConnector) createTXTRecord(zone, fqdn, value string, ttl int)
 	return nil
 }
 
-// ลบ TXT record
-func (c *technitiumConnector) deleteTXTRecord(zone, fqdn, value string) error {
+// ลบ TXT record โดยลบเฉพาะ value ที่ระบุ ไม่กระทบ TXT record อื่นที่ชื่อเดียวกัน
+// ถ้า value นี้ไม่มีอยู่แล้ว (เช่นถูกลบไปก่อนหน้าหรือ cert-manager เรียก CleanUp ซ้ำ) ถือว่าสำเร็จ
+func (c *technitiumConnector) deleteTXTRecord(ctx context.Context, zone, fqdn, value string) error {
 	// เอา trailing dot ออกถ้ามี
 	domain := strings.TrimSuffix(fqdn, ".")
 	zone = strings.TrimSuffix(zone, ".")
 
-	klog.Infof("Deleting TXT record for %s with value %s (zone: %s)", domain, value, zone)
+	existing, err := c.getTXTRecords(ctx, zone, fqdn)
+	if err != nil {
+		klog.V(4).Infof("Could not list existing TXT records for %s before delete, proceeding anyway: %v", domain, err)
+	} else if !containsString(existing, value) {
+		klog.Infof("TXT record for %s with value %s already absent, nothing to clean up", domain, value)
+		return nil
+	}
 
-	// สร้าง URL สำหรับเรียก API
-	endpoint := fmt.Sprintf("%s/api/zones/records/delete", c.serverURL)
+	klog.Infof("Deleting TXT record for %s with value %s (zone: %s)", domain, value, zone)
 
 	// สร้างพารามิเตอร์สำหรับ HTTP request
-	data := url.Values{}
-	data.Set("token", c.authToken)
-	data.Set("domain", domain)
-	data.Set("zone", zone)
-	data.Set("type", "TXT")
-	data.Set("text", value)
-	data.Set("splitText", "false")
-
-	fmt.Println("deleteTXTRecord:", data)
-	// ส่ง HTTP request
-	resp, err := HTTPClient.PostForm(endpoint, data)
+	form := url.Values{}
+	form.Set("domain", domain)
+	form.Set("zone", zone)
+	form.Set("type", "TXT")
+	form.Set("text", value)
+	form.Set("splitText", "false")
+
+	body, err := c.do(ctx, http.MethodPost, "/api/zones/records/delete", form)
 	if err != nil {
 		klog.Errorf("HTTP request failed: %v", err)
 		return err
 	}
-	defer resp.Body.Close()
-
-	// อ่านผลลัพธ์
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		klog.Errorf("Failed to read response body: %v", err)
-		return err
-	}
 
 	// ตรวจสอบว่าการเรียก API สำเร็จหรือไม่
 	var response struct {
@@ -212,66 +545,70 @@ func (c *technitiumConnector) deleteTXTRecord(zone, fqdn, value string) error {
 	return nil
 }
 
-// // getTXTRecords ดึงข้อมูล TXT records ทั้งหมดของ domain
-// func (c *technitiumConnector) getTXTRecords(fqdn string) ([]string, error) {
-// 	// เอา trailing dot ออกถ้ามี
-// 	domain := strings.TrimSuffix(fqdn, ".")
-
-// 	klog.V(4).Infof("Getting TXT records for %s", domain)
-
-// 	// สร้าง URL สำหรับเรียก API
-// 	endpoint := fmt.Sprintf("%s/api/dns/query?token=%s&name=%s&type=TXT", c.serverURL, url.QueryEscape(c.authToken), url.QueryEscape(domain))
-
-// 	// ส่ง HTTP request
-// 	resp, err := HTTPClient.Get(endpoint)
-// 	if err != nil {
-// 		klog.V(4).Infof("HTTP request failed: %v", err)
-// 		return nil, err
-// 	}
-// 	defer resp.Body.Close()
-
-// 	// อ่านผลลัพธ์
-// 	body, err := io.ReadAll(resp.Body)
-// 	if err != nil {
-// 		klog.V(4).Infof("Failed to read response body: %v", err)
-// 		return nil, err
-// 	}
-
-// 	// ตรวจสอบว่าการเรียก API สำเร็จหรือไม่
-// 	var response struct {
-// 		Status   string `json:"status"`
-// 		Response struct {
-// 			Answer []struct {
-// 				Name  string `json:"name"`
-// 				Type  string `json:"type"`
-// 				TTL   int    `json:"ttl"`
-// 				Value string `json:"value"`
-// 			} `json:"answer"`
-// 		} `json:"response"`
-// 	}
-
-// 	if err := json.Unmarshal(body, &response); err != nil {
-// 		klog.V(4).Infof("Failed to parse API response: %v", err)
-// 		return nil, fmt.Errorf("error parsing API response: %v", err)
-// 	}
-
-// 	if response.Status != "ok" {
-// 		klog.V(4).Infof("API returned non-ok status: %s", response.Status)
-// 		return nil, fmt.Errorf("API error")
-// 	}
-
-// 	var records []string
-// 	for _, answer := range response.Response.Answer {
-// 		if answer.Type == "TXT" {
-// 			// TXT records มาในรูปแบบที่มีเครื่องหมาย quote ล้อมรอบ จึงต้องนำออก
-// 			value := strings.Trim(answer.Value, "\"")
-// 			records = append(records, value)
-// 			klog.V(4).Infof("Found TXT record: %s", value)
-// 		}
-// 	}
-
-// 	return records, nil
-// }
+// getTXTRecords ดึงค่า TXT record ทั้งหมดที่อยู่ที่ fqdn ภายใน zone ที่ระบุจาก Technitium
+// โดยตรง ใช้เพื่อเช็คว่า value ที่จะเพิ่ม/ลบมีอยู่แล้วหรือไม่ ก่อนเรียก add/delete
+func (c *technitiumConnector) getTXTRecords(ctx context.Context, zone, fqdn string) ([]string, error) {
+	// เอา trailing dot ออกถ้ามี
+	domain := strings.TrimSuffix(fqdn, ".")
+	zone = strings.TrimSuffix(zone, ".")
+
+	klog.V(4).Infof("Getting TXT records for %s (zone: %s)", domain, zone)
+
+	form := url.Values{}
+	form.Set("domain", domain)
+	form.Set("zone", zone)
+	form.Set("listZone", "false")
+
+	body, err := c.do(ctx, http.MethodGet, "/api/zones/records/get", form)
+	if err != nil {
+		klog.V(4).Infof("HTTP request failed: %v", err)
+		return nil, err
+	}
+
+	// ตรวจสอบว่าการเรียก API สำเร็จหรือไม่
+	var response struct {
+		Status   string `json:"status"`
+		Response struct {
+			Records []struct {
+				Name  string `json:"name"`
+				Type  string `json:"type"`
+				RData struct {
+					Text string `json:"text"`
+				} `json:"rData"`
+			} `json:"records"`
+		} `json:"response"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		klog.V(4).Infof("Failed to parse API response: %v", err)
+		return nil, fmt.Errorf("error parsing API response: %v", err)
+	}
+
+	if response.Status != "ok" {
+		klog.V(4).Infof("API returned non-ok status: %s", response.Status)
+		return nil, fmt.Errorf("API error listing records for %s", domain)
+	}
+
+	var records []string
+	for _, rec := range response.Response.Records {
+		if rec.Type == "TXT" && strings.EqualFold(strings.TrimSuffix(rec.Name, "."), domain) {
+			records = append(records, rec.RData.Text)
+			klog.V(4).Infof("Found TXT record: %s", rec.RData.Text)
+		}
+	}
+
+	return records, nil
+}
+
+// containsString คืนค่า true ถ้า values มี value อยู่
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
 
 // loadConfig แปลงค่า JSON เป็นโครงสร้าง config
 func loadConfig(cfgJSON *extapi.JSON) (technitiumDNSProviderConfig, error) {